@@ -0,0 +1,278 @@
+package forwarder
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/tg"
+	"go.uber.org/zap"
+
+	"github.com/iyear/tdl/pkg/logger"
+	"github.com/iyear/tdl/pkg/tmedia"
+)
+
+// defaultPartSize is used when CloneOptions.PartSize isn't set.
+const defaultPartSize = 512 * 1024
+
+// bigFileThreshold is Telegram's cutoff above which parts must be saved via
+// upload.saveBigFilePart instead of upload.saveFilePart.
+const bigFileThreshold = 10 * 1024 * 1024
+
+// ChunkProgress receives cumulative-bytes-done updates as CloneMedia works
+// through a file's parts, whether they're freshly uploaded or skipped
+// because Options.Resume already has them.
+type ChunkProgress interface {
+	Chunk(done, total int64)
+}
+
+// uploadProgress adapts CloneMedia's ChunkProgress updates onto the
+// top-level per-Elem Progress, so a long clone upload shows up against the
+// elem it belongs to.
+type uploadProgress struct {
+	elem     *Elem
+	progress Progress
+}
+
+func (u uploadProgress) Chunk(done, total int64) {
+	u.progress.OnProgress(u.elem, done, total)
+}
+
+// nopProgress discards chunk updates, for clones (like document thumbnails)
+// too small to be worth reporting on.
+type nopProgress struct{}
+
+func (nopProgress) Chunk(int64, int64) {}
+
+// CloneOptions configures a single CloneMedia call.
+type CloneOptions struct {
+	Media    tmedia.Media
+	PartSize int
+	Progress ChunkProgress
+}
+
+// CloneMedia re-uploads opts.Media to Telegram in opts.PartSize chunks and
+// returns the resulting InputFileClass for use in a send request. If
+// Options.Resume is set, it's consulted before each chunk: a part already
+// recorded there is skipped entirely (not even re-downloaded from the
+// source), so retrying a failed clone or resuming a killed `tdl forward
+// --mode clone` doesn't re-transfer data Telegram already has.
+func (f *Forwarder) CloneMedia(ctx context.Context, opts CloneOptions, dryRun bool) (tg.InputFileClass, error) {
+	loc, size, dc, err := mediaLocation(opts.Media)
+	if err != nil {
+		return nil, err
+	}
+
+	partSize := opts.PartSize
+	if partSize <= 0 {
+		partSize = defaultPartSize
+	}
+	totalParts := int((size + int64(partSize) - 1) / int64(partSize))
+	big := size > bigFileThreshold
+
+	progress := opts.Progress
+	if progress == nil {
+		progress = nopProgress{}
+	}
+
+	key, err := mediaKey(opts.Media)
+	if err != nil {
+		return nil, err
+	}
+
+	fileID, err := f.cloneFileID(key, dryRun)
+	if err != nil {
+		return nil, errors.Wrap(err, "assign file id")
+	}
+
+	if f.opts.Resume != nil {
+		if err := f.opts.Resume.Prune(); err != nil {
+			logger.From(ctx).Warn("prune part store", zap.Error(err))
+		}
+	}
+
+	upload := f.uploadClient(ctx, dryRun)
+
+	var done int64
+	for part := 0; part < totalParts; part++ {
+		n := int64(partSize)
+		if part == totalParts-1 {
+			n = size - int64(part)*int64(partSize)
+		}
+
+		if f.opts.Resume != nil {
+			uploaded, err := f.opts.Resume.Uploaded(fileID, part)
+			if err != nil {
+				return nil, errors.Wrap(err, "check part store")
+			}
+			if uploaded {
+				done += n
+				progress.Chunk(done, size)
+				continue
+			}
+		}
+
+		chunk, err := f.downloadChunk(ctx, dc, loc, int64(part)*int64(partSize), int(n), dryRun)
+		if err != nil {
+			return nil, errors.Wrap(err, "download chunk")
+		}
+
+		ok, err := saveFilePart(ctx, upload, big, fileID, part, totalParts, chunk, dryRun)
+		if err != nil {
+			return nil, errors.Wrap(err, "save file part")
+		}
+		if !ok {
+			return nil, errors.Errorf("save file part %d/%d was rejected", part, totalParts)
+		}
+
+		if f.opts.Resume != nil {
+			if err := f.opts.Resume.MarkUploaded(fileID, part, sha256Chunk(chunk)); err != nil {
+				logger.From(ctx).Warn("mark part uploaded", zap.Error(err))
+			}
+		}
+
+		done += int64(len(chunk))
+		progress.Chunk(done, size)
+	}
+
+	name := fmt.Sprintf("%d.bin", fileID)
+	if big {
+		return &tg.InputFileBig{ID: fileID, Parts: totalParts, Name: name}, nil
+	}
+	return &tg.InputFile{ID: fileID, Parts: totalParts, Name: name}, nil
+}
+
+// cloneFileID returns the file_id CloneMedia should upload under: a stable,
+// store-backed id when Options.Resume is set and this isn't a dry run (so
+// retries/resumes reuse it), or a fresh random one each call otherwise,
+// matching the pre-resume behavior.
+func (f *Forwarder) cloneFileID(mediaKey int64, dryRun bool) (int64, error) {
+	if f.opts.Resume == nil || dryRun {
+		return f.randInt63(), nil
+	}
+	return f.opts.Resume.FileID(mediaKey, f.randInt63)
+}
+
+// downloadChunk reads limit bytes at offset from loc, hosted on dc. A dry
+// run returns a zero-filled buffer of the right size without touching the
+// network, matching how the rest of this package treats Options.DryRun.
+func (f *Forwarder) downloadChunk(ctx context.Context, dc int, loc tg.InputFileLocationClass, offset int64, limit int, dryRun bool) ([]byte, error) {
+	if dryRun {
+		return make([]byte, limit), nil
+	}
+
+	client := f.opts.Pool.Client(ctx, dc)
+	res, err := client.UploadGetFile(ctx, &tg.UploadGetFileRequest{
+		Location: loc,
+		Offset:   offset,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	file, ok := res.(*tg.UploadFile)
+	if !ok {
+		return nil, errors.Errorf("unexpected upload.getFile response %T", res)
+	}
+	return file.Bytes, nil
+}
+
+// saveFilePart pushes one chunk to Telegram via the big- or small-file part
+// RPC, matching Telegram's own size-based split. A dry run reports success
+// without invoking the RPC: client is a nopInvoker in that case, whose zero
+// value result would otherwise read back as "rejected".
+func saveFilePart(ctx context.Context, client *tg.Client, big bool, fileID int64, part, total int, bytes []byte, dryRun bool) (bool, error) {
+	if dryRun {
+		return true, nil
+	}
+
+	if big {
+		return client.UploadSaveBigFilePart(ctx, &tg.UploadSaveBigFilePartRequest{
+			FileID:         fileID,
+			FilePart:       part,
+			FileTotalParts: total,
+			Bytes:          bytes,
+		})
+	}
+	return client.UploadSaveFilePart(ctx, &tg.UploadSaveFilePartRequest{
+		FileID:   fileID,
+		FilePart: part,
+		Bytes:    bytes,
+	})
+}
+
+// mediaLocation resolves the source location, size and DC of media, so
+// CloneMedia can read it back in chunks.
+func mediaLocation(media tmedia.Media) (tg.InputFileLocationClass, int64, int, error) {
+	switch m := media.(type) {
+	case *tg.Document:
+		return &tg.InputDocumentFileLocation{
+			ID:            m.ID,
+			AccessHash:    m.AccessHash,
+			FileReference: m.FileReference,
+		}, m.Size, m.DC, nil
+	case *tg.Photo:
+		thumbType, size, ok := largestPhotoSize(m)
+		if !ok {
+			return nil, 0, 0, errors.Errorf("photo %d has no sizes to clone", m.ID)
+		}
+		return &tg.InputPhotoFileLocation{
+			ID:            m.ID,
+			AccessHash:    m.AccessHash,
+			FileReference: m.FileReference,
+			ThumbSize:     thumbType,
+		}, size, m.DC, nil
+	default:
+		return nil, 0, 0, errors.Errorf("unsupported media %T for clone", media)
+	}
+}
+
+// mediaKey returns the source's own stable Telegram file ID, used as the key
+// PartStore.FileID persists the assigned upload file_id under.
+func mediaKey(media tmedia.Media) (int64, error) {
+	switch m := media.(type) {
+	case *tg.Document:
+		return m.ID, nil
+	case *tg.Photo:
+		return m.ID, nil
+	default:
+		return 0, errors.Errorf("unsupported media %T for clone", media)
+	}
+}
+
+// largestPhotoSize returns the type and byte size of p's largest size
+// variant by byte size, fixed and progressive JPEG sizes alike; on a tie the
+// first one listed wins.
+func largestPhotoSize(p *tg.Photo) (string, int64, bool) {
+	var bestType string
+	var bestSize int64
+
+	for _, s := range p.Sizes {
+		switch sz := s.(type) {
+		case *tg.PhotoSize:
+			if int64(sz.Size) > bestSize {
+				bestSize, bestType = int64(sz.Size), sz.Type
+			}
+		case *tg.PhotoSizeProgressive:
+			largest := 0
+			for _, v := range sz.Sizes {
+				if v > largest {
+					largest = v
+				}
+			}
+			if int64(largest) > bestSize {
+				bestSize, bestType = int64(largest), sz.Type
+			}
+		}
+	}
+	return bestType, bestSize, bestType != ""
+}
+
+// uploadClient returns the client CloneMedia should upload parts through.
+func (f *Forwarder) uploadClient(ctx context.Context, dryRun bool) *tg.Client {
+	if dryRun {
+		return tg.NewClient(nopInvoker{})
+	}
+	return f.opts.Pool.Default(ctx)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"sync"
 	"time"
 
 	"github.com/go-faster/errors"
@@ -31,11 +32,31 @@ type Iter interface {
 	Err() error
 }
 
+// Progress receives start/finish events for each Elem the Forwarder
+// processes, plus in-flight byte counts while CloneMedia uploads a media
+// file, so a caller can drive a CLI progress bar.
+//
+// When Options.Threads > 1, forwardConcurrent calls OnAdd/OnDone/OnProgress
+// for different Elems from multiple worker goroutines at once, with no
+// synchronization on the Forwarder's side. Implementations must be safe for
+// concurrent use.
+type Progress interface {
+	OnAdd(elem *Elem)
+	OnDone(elem *Elem, err error)
+	OnProgress(elem *Elem, done, total int64)
+}
+
 type Elem struct {
 	From peers.Peer
 	Msg  *tg.Message
 	To   peers.Peer
 
+	// ToTopic is the destination forum topic's root message id, or 0 to
+	// send into General/a non-forum chat. The iterator sets this
+	// explicitly; if it's left 0 and To is a forum, forwardMessage still
+	// tries to auto-map the source message's own topic, see resolveTopic.
+	ToTopic int
+
 	Silent bool
 	DryRun bool
 	Mode   Mode
@@ -46,26 +67,77 @@ type Options struct {
 	PartSize int
 	Iter     Iter
 	Progress Progress
+
+	// Threads controls how many goroutines concurrently consume Iter and
+	// call forwardMessage. Values <= 1 preserve the original sequential
+	// behavior. Each worker shares the same Pool, so per-DC rate limiting
+	// is still required to avoid tripping FLOOD_WAIT.
+	Threads int
+
+	// MaxFloodWaitRetries caps how many times invoke and forwardMessage's
+	// CloneMedia retry loop will sleep through a FLOOD_WAIT_X for the same
+	// call before giving up and
+	// returning the error, so a chat that keeps flooding doesn't retry
+	// forever. <= 0 uses defaultMaxFloodWaitRetries.
+	MaxFloodWaitRetries int
+
+	// Transform, if set, is called for every message that's about to be
+	// re-sent (forwardTextOnly and the clone media paths; it's skipped for
+	// ModeDirect's raw ForwardIDs, since that copies the message by
+	// reference and has no content to rewrite). It lets callers strip a
+	// watermark caption, prepend a tag, remap entities or the reply markup,
+	// etc. keep=false skips sending msg entirely, but it's still recorded in
+	// f.sent so grouped siblings aren't resent later in the same Forward.
+	Transform func(ctx context.Context, elem *Elem, msg *tg.Message) (text string, entities []tg.MessageEntityClass, replyMarkup tg.ReplyMarkupClass, keep bool, err error)
+
+	// Resume, if set, is consulted by CloneMedia before re-uploading a
+	// chunk: a part already recorded (and not yet expired) is skipped, so
+	// retrying or resuming an interrupted clone of a large file or album
+	// doesn't re-upload parts Telegram already has. Leave nil to always
+	// upload every part, as before.
+	Resume *PartStore
+
+	// CreateTopics opts in to resolveTopic creating a destination forum
+	// topic (via channels.CreateForumTopic) when a message's source topic
+	// has no same-titled counterpart there yet. It's opt-in, not default,
+	// because creating a topic is a visible side effect on someone else's
+	// chat; with it false, auto-mapping only ever reuses an existing topic.
+	CreateTopics bool
 }
 
 type Forwarder struct {
-	sent map[[2]int64]struct{} // used to filter grouped messages which are already sent
-	rand *rand.Rand
+	sentMu sync.Mutex
+	sent   map[[2]int64]struct{} // used to filter grouped messages which are already sent
+
+	randMu sync.Mutex
+	rand   *rand.Rand
+
+	topicMu    sync.Mutex
+	topicCache map[topicCacheKey]int    // resolved (destination channel, title) -> topic id, see resolveTopic
+	titleCache map[titleCacheKey]string // (source channel, source topic) -> topic title, see mappedTopic
+
 	opts Options
+
+	limiters *dcLimiters
 }
 
 func New(opts Options) *Forwarder {
 	return &Forwarder{
-		sent: make(map[[2]int64]struct{}),
-		rand: rand.New(rand.NewSource(time.Now().UnixNano())),
-		opts: opts,
+		sent:     make(map[[2]int64]struct{}),
+		rand:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		opts:     opts,
+		limiters: newDCLimiters(),
 	}
 }
 
 func (f *Forwarder) Forward(ctx context.Context) error {
+	if f.opts.Threads > 1 {
+		return f.forwardConcurrent(ctx)
+	}
+
 	for f.opts.Iter.Next(ctx) {
 		elem := f.opts.Iter.Value()
-		if _, ok := f.sent[f.sentTuple(elem.From, elem.Msg)]; ok {
+		if f.isSent(elem.From, elem.Msg) {
 			// skip grouped messages
 			continue
 		}
@@ -95,14 +167,158 @@ func (f *Forwarder) Forward(ctx context.Context) error {
 	return f.opts.Iter.Err()
 }
 
+// forwardConcurrent fans elements out to f.opts.Threads workers. The iterator
+// itself is only ever touched from the calling goroutine, since most Iter
+// implementations are not safe for concurrent use; workers only process
+// already-fetched elements.
+func (f *Forwarder) forwardConcurrent(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	jobs := make(chan *Elem, f.opts.Threads)
+	errCh := make(chan error, 1)
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.opts.Threads; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for elem := range jobs {
+				if f.isSent(elem.From, elem.Msg) {
+					continue
+				}
+
+				if _, ok := elem.Msg.GetGroupedID(); ok {
+					grouped, err := utils.Telegram.GetGroupedMessages(ctx, f.opts.Pool.Default(ctx), elem.From.InputPeer(), elem.Msg)
+					if err != nil {
+						continue
+					}
+
+					_ = f.forwardMessage(ctx, elem, grouped...)
+					continue
+				}
+
+				if err := f.forwardMessage(ctx, elem); err != nil {
+					if errors.Is(err, context.Canceled) {
+						select {
+						case errCh <- err:
+							cancel()
+						default:
+						}
+					}
+				}
+			}
+		}()
+	}
+
+	// claimedGroups records which albums have already been handed to a
+	// worker, keyed by (from peer, grouped id). It's only ever touched here
+	// in the single dispatch goroutine, so it needs no locking. Without it,
+	// every message of an album is queued as its own Elem, and two workers
+	// can dequeue two siblings before either's forwardMessage call reaches
+	// markSent, so both independently call GetGroupedMessages and resend
+	// the whole album. Claiming a group here, before it ever reaches a
+	// worker, means only the first sibling seen is ever dispatched; that
+	// single forwardMessage call still fetches and sends every sibling via
+	// GetGroupedMessages.
+	claimedGroups := make(map[[2]int64]struct{})
+
+loop:
+	for f.opts.Iter.Next(ctx) {
+		elem := f.opts.Iter.Value()
+
+		if gid, ok := elem.Msg.GetGroupedID(); ok {
+			key := [2]int64{elem.From.ID(), gid}
+			if _, ok := claimedGroups[key]; ok {
+				continue
+			}
+			claimedGroups[key] = struct{}{}
+		}
+
+		select {
+		case <-ctx.Done():
+			break loop
+		case jobs <- elem:
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	select {
+	case err := <-errCh:
+		return err
+	default:
+	}
+
+	if err := f.opts.Iter.Err(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+func (f *Forwarder) isSent(peer peers.Peer, msg *tg.Message) bool {
+	f.sentMu.Lock()
+	defer f.sentMu.Unlock()
+
+	_, ok := f.sent[f.sentTuple(peer, msg)]
+	return ok
+}
+
+func (f *Forwarder) markSent(peer peers.Peer, msg *tg.Message) {
+	f.sentMu.Lock()
+	defer f.sentMu.Unlock()
+
+	f.sent[f.sentTuple(peer, msg)] = struct{}{}
+}
+
+// randInt63 is a concurrency-safe rand.Int63, since f.rand is shared across
+// worker goroutines when Options.Threads > 1.
+func (f *Forwarder) randInt63() int64 {
+	f.randMu.Lock()
+	defer f.randMu.Unlock()
+
+	return f.rand.Int63()
+}
+
+// randInt63n is a concurrency-safe rand.Int63n.
+func (f *Forwarder) randInt63n(n int64) int64 {
+	f.randMu.Lock()
+	defer f.randMu.Unlock()
+
+	return f.rand.Int63n(n)
+}
+
+// applyTransform runs opts.Transform, if set, over msg and returns the
+// message that should actually be sent. keep=false means the caller should
+// skip sending msg, without that being treated as an error.
+func (f *Forwarder) applyTransform(ctx context.Context, elem *Elem, msg *tg.Message) (out *tg.Message, keep bool, err error) {
+	if f.opts.Transform == nil {
+		return msg, true, nil
+	}
+
+	text, entities, replyMarkup, keep, err := f.opts.Transform(ctx, elem, msg)
+	if err != nil {
+		return nil, false, errors.Wrap(err, "transform")
+	}
+	if !keep {
+		return nil, false, nil
+	}
+
+	transformed := *msg
+	transformed.Message = text
+	transformed.Entities = entities
+	transformed.ReplyMarkup = replyMarkup
+	return &transformed, true, nil
+}
+
 func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*tg.Message) (rerr error) {
 	f.opts.Progress.OnAdd(elem)
 	defer func() {
-		f.sent[f.sentTuple(elem.From, elem.Msg)] = struct{}{}
+		f.markSent(elem.From, elem.Msg)
 
 		// grouped message also should be marked as sent
 		for _, m := range grouped {
-			f.sent[f.sentTuple(elem.From, m)] = struct{}{}
+			f.markSent(elem.From, m)
 		}
 		f.opts.Progress.OnDone(elem, rerr)
 	}()
@@ -112,29 +328,53 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 		zap.Int64("to", elem.To.ID()),
 		zap.Int("message", elem.Msg.ID))
 
+	replyTo, err := f.resolveTopic(ctx, elem)
+	if err != nil {
+		log.Warn("resolve destination topic, sending to General instead", zap.Error(err))
+		replyTo = nil
+	}
+
 	forwardTextOnly := func(msg *tg.Message) error {
 		if msg.Message == "" {
 			return errors.Errorf("empty message content, skip send: %d", msg.ID)
 		}
-		req := &tg.MessagesSendMessageRequest{
-			NoWebpage:              false,
-			Silent:                 elem.Silent,
-			Background:             false,
-			ClearDraft:             false,
-			Noforwards:             false,
-			UpdateStickersetsOrder: false,
-			Peer:                   elem.To.InputPeer(),
-			ReplyTo:                nil,
-			Message:                msg.Message,
-			RandomID:               f.rand.Int63(),
-			ReplyMarkup:            msg.ReplyMarkup,
-			Entities:               msg.Entities,
-			ScheduleDate:           0,
-			SendAs:                 nil,
+
+		msg, keep, err := f.applyTransform(ctx, elem, msg)
+		if err != nil {
+			return err
 		}
-		req.SetFlags()
+		if !keep {
+			return nil
+		}
+
+		// minted once, before the retry loop: a transient-error retry may be
+		// retrying a send Telegram already committed, and reusing the same
+		// RandomID is what lets Telegram's own dedup collapse that into a
+		// no-op instead of sending the message twice
+		randomID := f.randInt63()
+		err = f.invoke(ctx, elem, func(client *tg.Client) error {
+			req := &tg.MessagesSendMessageRequest{
+				NoWebpage:              false,
+				Silent:                 elem.Silent,
+				Background:             false,
+				ClearDraft:             false,
+				Noforwards:             false,
+				UpdateStickersetsOrder: false,
+				Peer:                   elem.To.InputPeer(),
+				ReplyTo:                replyTo,
+				Message:                msg.Message,
+				RandomID:               randomID,
+				ReplyMarkup:            msg.ReplyMarkup,
+				Entities:               msg.Entities,
+				ScheduleDate:           0,
+				SendAs:                 nil,
+			}
+			req.SetFlags()
 
-		if _, err := f.forwardClient(ctx, elem).MessagesSendMessage(ctx, req); err != nil {
+			_, err := client.MessagesSendMessage(ctx, req)
+			return err
+		})
+		if err != nil {
 			return errors.Wrap(err, "send message")
 		}
 		return nil
@@ -147,43 +387,97 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 			return nil, errors.Errorf("message %d is not a media message", msg.ID)
 		}
 
+		// paid/extended media (e.g. premium channel posts) wrap the actual
+		// photo or document behind messageMediaInvoice.extended_media; unwrap
+		// it so the rest of this function can treat it like any other photo
+		// or document, and bail out to a text fallback while it's still a
+		// paywalled preview
+		resolved := msg.Media
+		if inv, ok := msg.Media.(*tg.MessageMediaInvoice); ok {
+			inner, ok := unlockedExtendedMedia(inv)
+			if !ok {
+				log.Warn("paid media is still locked, sending text fallback",
+					zap.Int64("peer", elem.From.ID()),
+					zap.Int("message", msg.ID))
+				return nil, errors.Errorf("extended media %d is a locked preview", msg.ID)
+			}
+			resolved = inner
+		}
+
 		// if it's a media message, but it's not protected, convert it to InputMediaClass
 		// or if it's protected, but it doesn't contain photo or document,
 
 		// we should clone photo and document via re-upload, it will be banned if we forward it directly.
 		// but other media can be forwarded directly via copy
-		if (!protectedDialog(elem.From) && !protectedMessage(msg)) || !photoOrDocument(msg.Media) {
-			media, ok := tmedia.ConvInputMedia(msg.Media)
+		//
+		// unlocked extended media never takes this shortcut, even when the
+		// dialog isn't protected: resolved is the inner photo/document, not
+		// something Telegram will accept a by-reference copy of the
+		// wrapping messageMediaInvoice for, so it always goes through the
+		// clone branch below.
+		if resolved == msg.Media && ((!protectedDialog(elem.From) && !protectedMessage(msg)) || !photoOrDocument(resolved)) {
+			media, ok := tmedia.ConvInputMedia(resolved)
 			if !ok {
 				return nil, errors.Errorf("can't convert message %d to input class directly", msg.ID)
 			}
 			return media, nil
 		}
 
-		media, ok := tmedia.GetMedia(msg)
+		mediaMsg := msg
+		if resolved != msg.Media {
+			clone := *msg
+			clone.Media = resolved
+			mediaMsg = &clone
+		}
+
+		media, ok := tmedia.GetMedia(mediaMsg)
 		if !ok {
 			log.Warn("Can't get media from message",
 				zap.Int64("peer", elem.From.ID()),
 				zap.Int("message", msg.ID))
 
 			// unsupported re-upload media
-			return nil, errors.Errorf("unsupported media %T", msg.Media)
+			return nil, errors.Errorf("unsupported media %T", resolved)
 		}
 
-		mediaFile, err := f.CloneMedia(ctx, CloneOptions{
-			Media:    media,
-			PartSize: f.opts.PartSize,
-			Progress: uploadProgress{
-				elem:     elem,
-				progress: f.opts.Progress,
-			},
-		}, elem.DryRun)
-		if err != nil {
-			return nil, errors.Wrap(err, "clone media")
+		// throttle per source DC, so concurrent workers don't pile onto the
+		// same DC and trip FLOOD_WAIT
+		dc := mediaDC(media)
+
+		var mediaFile tg.InputFileClass
+		for attempt := 0; ; attempt++ {
+			if err := f.limiters.wait(ctx, dc); err != nil {
+				return nil, errors.Wrap(err, "wait rate limiter")
+			}
+
+			file, err := f.CloneMedia(ctx, CloneOptions{
+				Media:    media,
+				PartSize: f.opts.PartSize,
+				Progress: uploadProgress{
+					elem:     elem,
+					progress: f.opts.Progress,
+				},
+			}, elem.DryRun)
+			if err == nil {
+				mediaFile = file
+				break
+			}
+
+			// reuse the same FLOOD_WAIT/transient retry policy as the send
+			// path; CloneMedia downloads each chunk from the media's own DC
+			// via Pool.Client, and a migrate error is not retried here: only
+			// flood wait/transient errors are
+			action, werr := f.waitRetry(ctx, log, attempt, err)
+			if action != retryFloodWait && action != retryTransient {
+				return nil, errors.Wrap(err, "clone media")
+			}
+			if werr != nil {
+				return nil, werr
+			}
 		}
 
 		// now we only have to process cloned photo or document
-		switch m := msg.Media.(type) {
+		switch m := resolved.(type) {
 		case *tg.MessageMediaPhoto:
 			photo := &tg.InputMediaUploadedPhoto{
 				Spoiler:    m.Spoiler,
@@ -227,7 +521,7 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 
 			return document, nil
 		default:
-			return nil, errors.Errorf("unsupported media %T", msg.Media)
+			return nil, errors.Errorf("unsupported media %T", resolved)
 		}
 	}
 
@@ -235,26 +529,24 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 	case ModeDirect:
 		// it can be forwarded via API
 		if !protectedDialog(elem.From) && !protectedMessage(elem.Msg) {
-			builder := message.NewSender(f.forwardClient(ctx, elem)).
-				To(elem.To.InputPeer()).CloneBuilder()
-			if elem.Silent {
-				builder = builder.Silent()
-			}
-
+			ids := []int{elem.Msg.ID}
 			if len(grouped) > 0 {
-				ids := make([]int, 0, len(grouped))
+				ids = make([]int, 0, len(grouped))
 				for _, m := range grouped {
 					ids = append(ids, m.ID)
 				}
+			}
 
-				if _, err := builder.ForwardIDs(elem.From.InputPeer(), ids[0], ids[1:]...).Send(ctx); err != nil {
-					goto fallback
+			err := f.invoke(ctx, elem, func(client *tg.Client) error {
+				builder := message.NewSender(client).To(elem.To.InputPeer()).CloneBuilder()
+				if elem.Silent {
+					builder = builder.Silent()
 				}
 
-				return nil
-			}
-
-			if _, err := builder.ForwardIDs(elem.From.InputPeer(), elem.Msg.ID).Send(ctx); err != nil {
+				_, err := builder.ForwardIDs(elem.From.InputPeer(), ids[0], ids[1:]...).Send(ctx)
+				return err
+			})
+			if err != nil {
 				goto fallback
 			}
 			return nil
@@ -271,31 +563,49 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 					continue
 				}
 
+				tm, keep, err := f.applyTransform(ctx, elem, gm)
+				if err != nil {
+					return err
+				}
+				if !keep {
+					continue
+				}
+
 				single := tg.InputSingleMedia{
 					Media:    m,
-					RandomID: f.rand.Int63(),
-					Message:  gm.Message,
-					Entities: gm.Entities,
+					RandomID: f.randInt63(),
+					Message:  tm.Message,
+					Entities: tm.Entities,
 				}
 				single.SetFlags()
 				media = append(media, single)
 			}
 
 			if len(media) > 0 {
-				req := &tg.MessagesSendMultiMediaRequest{
-					Silent:                 elem.Silent,
-					Background:             false,
-					ClearDraft:             false,
-					Noforwards:             false,
-					UpdateStickersetsOrder: false,
-					Peer:                   elem.To.InputPeer(),
-					ReplyTo:                nil,
-					MultiMedia:             media,
-					ScheduleDate:           0,
-					SendAs:                 nil,
-				}
-				req.SetFlags()
-				if _, err := f.forwardClient(ctx, elem).MessagesSendMultiMedia(ctx, req); err != nil {
+				// each single media's RandomID was minted once above, before
+				// the retry loop: a transient-error retry may be retrying a
+				// send Telegram already committed, and reusing the same
+				// RandomIDs is what lets Telegram's own dedup collapse that
+				// into a no-op instead of sending the album twice
+				err := f.invoke(ctx, elem, func(client *tg.Client) error {
+					req := &tg.MessagesSendMultiMediaRequest{
+						Silent:                 elem.Silent,
+						Background:             false,
+						ClearDraft:             false,
+						Noforwards:             false,
+						UpdateStickersetsOrder: false,
+						Peer:                   elem.To.InputPeer(),
+						ReplyTo:                replyTo,
+						MultiMedia:             media,
+						ScheduleDate:           0,
+						SendAs:                 nil,
+					}
+					req.SetFlags()
+
+					_, err := client.MessagesSendMultiMedia(ctx, req)
+					return err
+				})
+				if err != nil {
 					return errors.Wrap(err, "send multi media")
 				}
 				return nil
@@ -309,26 +619,43 @@ func (f *Forwarder) forwardMessage(ctx context.Context, elem *Elem, grouped ...*
 			log.Debug("Can't convert forwarded media", zap.Error(err))
 			return forwardTextOnly(elem.Msg)
 		}
-		// send text copy with forwarded media
-		req := &tg.MessagesSendMediaRequest{
-			Silent:                 elem.Silent,
-			Background:             false,
-			ClearDraft:             false,
-			Noforwards:             false,
-			UpdateStickersetsOrder: false,
-			Peer:                   elem.To.InputPeer(),
-			ReplyTo:                nil,
-			Media:                  media,
-			Message:                elem.Msg.Message,
-			RandomID:               rand.Int63(),
-			ReplyMarkup:            elem.Msg.ReplyMarkup,
-			Entities:               elem.Msg.Entities,
-			ScheduleDate:           0,
-			SendAs:                 nil,
+
+		msg, keep, err := f.applyTransform(ctx, elem, elem.Msg)
+		if err != nil {
+			return err
+		}
+		if !keep {
+			return nil
 		}
-		req.SetFlags()
 
-		if _, err := f.forwardClient(ctx, elem).MessagesSendMedia(ctx, req); err != nil {
+		// send text copy with forwarded media; RandomID is minted once,
+		// before the retry loop, so a transient-error retry reuses it and
+		// Telegram's own dedup collapses a retry of an already-committed
+		// send into a no-op instead of sending it twice
+		randomID := f.randInt63()
+		err = f.invoke(ctx, elem, func(client *tg.Client) error {
+			req := &tg.MessagesSendMediaRequest{
+				Silent:                 elem.Silent,
+				Background:             false,
+				ClearDraft:             false,
+				Noforwards:             false,
+				UpdateStickersetsOrder: false,
+				Peer:                   elem.To.InputPeer(),
+				ReplyTo:                replyTo,
+				Media:                  media,
+				Message:                msg.Message,
+				RandomID:               randomID,
+				ReplyMarkup:            msg.ReplyMarkup,
+				Entities:               msg.Entities,
+				ScheduleDate:           0,
+				SendAs:                 nil,
+			}
+			req.SetFlags()
+
+			_, err := client.MessagesSendMedia(ctx, req)
+			return err
+		})
+		if err != nil {
 			return errors.Wrap(err, "send single media")
 		}
 		return nil
@@ -370,11 +697,44 @@ func protectedMessage(msg *tg.Message) bool {
 	return msg.GetNoforwards()
 }
 
+// mediaDC returns the DC the given tmedia.Media is hosted on, or 0 if it
+// can't be determined, in which case callers fall back to a shared bucket.
+func mediaDC(media tmedia.Media) int {
+	switch m := media.(type) {
+	case *tg.Document:
+		return m.DC
+	case *tg.Photo:
+		return m.DC
+	default:
+		return 0
+	}
+}
+
 func photoOrDocument(media tg.MessageMediaClass) bool {
-	switch media.(type) {
+	switch m := media.(type) {
 	case *tg.MessageMediaPhoto, *tg.MessageMediaDocument:
 		return true
+	case *tg.MessageMediaInvoice:
+		_, ok := unlockedExtendedMedia(m)
+		return ok
 	default:
 		return false
 	}
 }
+
+// unlockedExtendedMedia returns the inner photo/document media wrapped by
+// inv's extended_media, if it has already been unlocked (e.g. the viewer
+// paid for it). A *tg.MessageExtendedMediaPreview means the paid media is
+// still locked behind a paywall, so there's nothing to clone yet.
+func unlockedExtendedMedia(inv *tg.MessageMediaInvoice) (tg.MessageMediaClass, bool) {
+	ext, ok := inv.GetExtendedMedia()
+	if !ok {
+		return nil, false
+	}
+
+	full, ok := ext.(*tg.MessageExtendedMedia)
+	if !ok {
+		return nil, false
+	}
+	return full.Media, true
+}
@@ -0,0 +1,80 @@
+package forwarder
+
+import (
+	"testing"
+
+	"github.com/gotd/td/tg"
+)
+
+func TestUnlockedExtendedMedia(t *testing.T) {
+	photo := &tg.MessageMediaPhoto{Photo: &tg.Photo{ID: 1}}
+
+	tests := []struct {
+		name string
+		inv  *tg.MessageMediaInvoice
+		want bool
+	}{
+		{
+			name: "unlocked",
+			inv:  invoiceWith(&tg.MessageExtendedMedia{Media: photo}),
+			want: true,
+		},
+		{
+			name: "locked preview",
+			inv:  invoiceWith(&tg.MessageExtendedMediaPreview{}),
+			want: false,
+		},
+		{
+			name: "no extended media",
+			inv:  invoiceWith(nil),
+			want: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			media, ok := unlockedExtendedMedia(tt.inv)
+			if ok != tt.want {
+				t.Fatalf("unlockedExtendedMedia() ok = %v, want %v", ok, tt.want)
+			}
+			if ok && media != photo {
+				t.Fatalf("unlockedExtendedMedia() returned %v, want the wrapped photo", media)
+			}
+		})
+	}
+}
+
+func TestPhotoOrDocument(t *testing.T) {
+	tests := []struct {
+		name  string
+		media tg.MessageMediaClass
+		want  bool
+	}{
+		{"photo", &tg.MessageMediaPhoto{Photo: &tg.Photo{ID: 1}}, true},
+		{"document", &tg.MessageMediaDocument{Document: &tg.Document{ID: 1}}, true},
+		{"unlocked extended media", invoiceWith(&tg.MessageExtendedMedia{
+			Media: &tg.MessageMediaPhoto{Photo: &tg.Photo{ID: 1}},
+		}), true},
+		{"locked extended media", invoiceWith(&tg.MessageExtendedMediaPreview{}), false},
+		{"unrelated media", &tg.MessageMediaGeo{}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := photoOrDocument(tt.media); got != tt.want {
+				t.Fatalf("photoOrDocument() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// invoiceWith builds a MessageMediaInvoice wrapping ext as its extended
+// media, or with no extended media at all when ext is nil.
+func invoiceWith(ext tg.MessageExtendedMediaClass) *tg.MessageMediaInvoice {
+	inv := &tg.MessageMediaInvoice{}
+	if ext != nil {
+		inv.ExtendedMedia = ext
+	}
+	inv.SetFlags()
+	return inv
+}
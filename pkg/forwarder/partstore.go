@@ -0,0 +1,188 @@
+package forwarder
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-faster/errors"
+	bolt "go.etcd.io/bbolt"
+)
+
+// partTTL is how long an uploaded-part record stays valid before Prune
+// removes it, matching Telegram's server-side retention for an in-progress
+// big file upload: parts older than this are no longer accepted by
+// upload.saveBigFilePart, so there's nothing to resume from past this point.
+const partTTL = 24 * time.Hour
+
+var (
+	partBucket   = []byte("parts")
+	fileIDBucket = []byte("file_ids")
+)
+
+// PartRecord is one chunk that's already been pushed to Telegram via
+// upload.saveFilePart/upload.saveBigFilePart, keyed by the stable FileID
+// CloneMedia assigns to the upload and the chunk's index within it.
+type PartRecord struct {
+	FileID     int64
+	PartIndex  int
+	SHA256     [32]byte
+	UploadedAt time.Time
+}
+
+func (r PartRecord) expired(now time.Time) bool {
+	return now.Sub(r.UploadedAt) > partTTL
+}
+
+// PartStore persists PartRecords across process restarts in a small bbolt
+// database, so CloneMedia can skip parts a previous, interrupted run already
+// uploaded instead of re-reading and re-sending the whole file. It's safe
+// for concurrent use.
+type PartStore struct {
+	db *bolt.DB
+}
+
+// OpenPartStore opens (creating if necessary) the part-state database at
+// path, e.g. filepath.Join(dataDir, "clone_parts.bolt").
+func OpenPartStore(path string) (*PartStore, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, errors.Wrap(err, "create part store dir")
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, errors.Wrap(err, "open part store")
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(partBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(fileIDBucket)
+		return err
+	}); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "create part bucket")
+	}
+
+	return &PartStore{db: db}, nil
+}
+
+func (s *PartStore) Close() error {
+	return s.db.Close()
+}
+
+// Uploaded reports whether part partIndex of fileID was already saved to
+// Telegram and the record hasn't expired. It's a presence check only: the
+// whole point is to skip re-reading and re-sending a part we already pushed,
+// so there's no local chunk to compare a sha256 against yet. The sha256
+// captured by MarkUploaded is for audit, not for gating this check.
+func (s *PartStore) Uploaded(fileID int64, partIndex int) (bool, error) {
+	var rec PartRecord
+	found := false
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		v := tx.Bucket(partBucket).Get(partKey(fileID, partIndex))
+		if v == nil {
+			return nil
+		}
+		if err := json.Unmarshal(v, &rec); err != nil {
+			return errors.Wrap(err, "decode part record")
+		}
+		found = true
+		return nil
+	})
+	if err != nil || !found {
+		return false, err
+	}
+
+	return !rec.expired(time.Now()), nil
+}
+
+// MarkUploaded records that part partIndex of fileID was saved to Telegram
+// with the given chunk's sha256.
+func (s *PartStore) MarkUploaded(fileID int64, partIndex int, sha [32]byte) error {
+	v, err := json.Marshal(PartRecord{
+		FileID:     fileID,
+		PartIndex:  partIndex,
+		SHA256:     sha,
+		UploadedAt: time.Now(),
+	})
+	if err != nil {
+		return errors.Wrap(err, "encode part record")
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(partBucket).Put(partKey(fileID, partIndex), v)
+	})
+}
+
+// FileID returns the upload file_id previously assigned to mediaKey (the
+// source document/photo's own Telegram ID), generating and persisting one
+// via gen if this is the first time mediaKey is seen. Reusing the same
+// file_id across retries and across process restarts is what lets Uploaded
+// find the parts a previous attempt already saved.
+func (s *PartStore) FileID(mediaKey int64, gen func() int64) (int64, error) {
+	key := partKey(mediaKey, 0)[:8]
+
+	var id int64
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(fileIDBucket)
+
+		if v := b.Get(key); v != nil {
+			id = int64(binary.BigEndian.Uint64(v))
+			return nil
+		}
+
+		id = gen()
+		v := make([]byte, 8)
+		binary.BigEndian.PutUint64(v, uint64(id))
+		return b.Put(key, v)
+	})
+	return id, err
+}
+
+// Prune deletes every record older than partTTL. bbolt has no built-in
+// expiry, so callers should run this periodically, e.g. once before each
+// CloneMedia batch.
+func (s *PartStore) Prune() error {
+	cutoff := time.Now().Add(-partTTL)
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(partBucket)
+		c := b.Cursor()
+
+		var stale [][]byte
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var rec PartRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				continue
+			}
+			if rec.UploadedAt.Before(cutoff) {
+				stale = append(stale, append([]byte(nil), k...))
+			}
+		}
+		for _, k := range stale {
+			if err := b.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// partKey encodes fileID/partIndex as a sortable bbolt key.
+func partKey(fileID int64, partIndex int) []byte {
+	key := make([]byte, 16)
+	binary.BigEndian.PutUint64(key[:8], uint64(fileID))
+	binary.BigEndian.PutUint64(key[8:], uint64(partIndex))
+	return key
+}
+
+// sha256Chunk hashes a chunk before marking it in a PartStore.
+func sha256Chunk(b []byte) [32]byte {
+	return sha256.Sum256(b)
+}
@@ -0,0 +1,43 @@
+package forwarder
+
+import (
+	"context"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// defaultDCRate is a conservative default for the clone/upload path, tuned to
+// stay well under Telegram's per-DC upload limits even when several workers
+// share the same DC.
+const defaultDCRate = rate.Limit(20)
+
+// dcLimiters hands out a token-bucket rate.Limiter per DC id, lazily created
+// on first use. It's safe for concurrent use by the worker pool added by
+// Options.Threads.
+type dcLimiters struct {
+	mu       sync.Mutex
+	limiters map[int]*rate.Limiter
+}
+
+func newDCLimiters() *dcLimiters {
+	return &dcLimiters{limiters: make(map[int]*rate.Limiter)}
+}
+
+func (d *dcLimiters) get(dc int) *rate.Limiter {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	l, ok := d.limiters[dc]
+	if !ok {
+		l = rate.NewLimiter(defaultDCRate, 1)
+		d.limiters[dc] = l
+	}
+	return l
+}
+
+// wait blocks until the DC identified by dc is allowed to send another
+// request, or ctx is done.
+func (d *dcLimiters) wait(ctx context.Context, dc int) error {
+	return d.get(dc).Wait(ctx)
+}
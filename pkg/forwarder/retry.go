@@ -0,0 +1,181 @@
+package forwarder
+
+import (
+	"context"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/tg"
+	"github.com/gotd/td/tgerr"
+	"go.uber.org/zap"
+
+	"github.com/iyear/tdl/pkg/logger"
+)
+
+const (
+	// maxFloodWait caps how long we'll sleep for a single FLOOD_WAIT_X,
+	// even if Telegram asks for longer.
+	maxFloodWait = 5 * time.Minute
+	// defaultMaxFloodWaitRetries bounds how many FLOOD_WAIT_X retries
+	// invoke and forwardMessage's CloneMedia retry loop will sit through
+	// before giving up, used when
+	// Options.MaxFloodWaitRetries isn't set. A chat that keeps returning
+	// FLOOD_WAIT would otherwise retry forever.
+	defaultMaxFloodWaitRetries = 10
+	// maxTransientRetries bounds retries for -500/timeout style errors,
+	// which aren't guaranteed to ever succeed.
+	maxTransientRetries = 5
+	maxTransientBackoff = 30 * time.Second
+)
+
+// maxFloodWaitRetries returns Options.MaxFloodWaitRetries, or
+// defaultMaxFloodWaitRetries if it's left unset.
+func (f *Forwarder) maxFloodWaitRetries() int {
+	if f.opts.MaxFloodWaitRetries > 0 {
+		return f.opts.MaxFloodWaitRetries
+	}
+	return defaultMaxFloodWaitRetries
+}
+
+// invoke runs fn against the client appropriate for elem, retrying on:
+//   - FILE_MIGRATE_X / USER_MIGRATE_X / PHONE_MIGRATE_X: switches to the
+//     target DC via Pool.Client and retries on the new client.
+//   - FLOOD_WAIT_X: sleeps for the requested duration (capped) and retries
+//     on the same client.
+//   - transient -500 and timeout errors: retries with exponential backoff
+//     and jitter, up to maxTransientRetries.
+//
+// fn may be called more than once, so it must be safe to repeat. Callers
+// mint any RandomID once, before invoke, and close over it, so a retry of a
+// send Telegram already committed reuses it and is deduplicated server-side.
+func (f *Forwarder) invoke(ctx context.Context, elem *Elem, fn func(client *tg.Client) error) error {
+	client := f.forwardClient(ctx, elem)
+	log := logger.From(ctx).With(zap.Int64("to", elem.To.ID()))
+
+	for attempt := 0; ; attempt++ {
+		err := fn(client)
+		if err == nil {
+			return nil
+		}
+
+		action, werr := f.waitRetry(ctx, log, attempt, err)
+		switch action {
+		case retryMigrate:
+			dc, _ := migrateDC(err)
+			client = f.opts.Pool.Client(ctx, dc)
+		case retryFloodWait, retryTransient:
+			if werr != nil {
+				return werr
+			}
+		default:
+			return err
+		}
+	}
+}
+
+// waitRetry inspects err and, if it's retryable, sleeps the appropriate
+// amount of time before returning the action that was taken. It's shared by
+// invoke and the clone/upload path so both retry FLOOD_WAIT and transient
+// errors the same way.
+func (f *Forwarder) waitRetry(ctx context.Context, log *zap.Logger, attempt int, err error) (retryAction, error) {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return retryNone, err
+	}
+
+	if dc, ok := migrateDC(err); ok {
+		log.Warn("migrating to new DC", zap.Int("dc", dc))
+		return retryMigrate, nil
+	}
+
+	if wait, ok := floodWait(err); ok {
+		if attempt >= f.maxFloodWaitRetries() {
+			log.Warn("giving up after repeated FLOOD_WAIT",
+				zap.Int("attempt", attempt), zap.Int("cap", f.maxFloodWaitRetries()))
+			return retryNone, err
+		}
+
+		if wait > maxFloodWait {
+			wait = maxFloodWait
+		}
+		log.Warn("FLOOD_WAIT, backing off", zap.Duration("wait", wait))
+		return retryFloodWait, f.sleep(ctx, wait)
+	}
+
+	if attempt < maxTransientRetries && isTransient(err) {
+		log.Warn("transient error, retrying", zap.Error(err), zap.Int("attempt", attempt))
+		return retryTransient, f.sleepBackoff(ctx, attempt)
+	}
+
+	return retryNone, err
+}
+
+type retryAction int
+
+const (
+	retryNone retryAction = iota
+	retryMigrate
+	retryFloodWait
+	retryTransient
+)
+
+// migrateDC reports the target DC if err is a FILE_MIGRATE_X / USER_MIGRATE_X
+// / PHONE_MIGRATE_X / NETWORK_MIGRATE_X RPC error.
+func migrateDC(err error) (int, bool) {
+	rpcErr, ok := tgerr.As(err)
+	if !ok {
+		return 0, false
+	}
+
+	typ := rpcErr.Type
+	if strings.HasPrefix(typ, "FILE_MIGRATE") ||
+		strings.HasPrefix(typ, "USER_MIGRATE") ||
+		strings.HasPrefix(typ, "PHONE_MIGRATE") ||
+		strings.HasPrefix(typ, "NETWORK_MIGRATE") {
+		return rpcErr.Argument, true
+	}
+	return 0, false
+}
+
+// floodWait reports the requested backoff if err is a FLOOD_WAIT_X RPC error.
+func floodWait(err error) (time.Duration, bool) {
+	rpcErr, ok := tgerr.As(err)
+	if !ok || !strings.HasPrefix(rpcErr.Type, "FLOOD_WAIT") {
+		return 0, false
+	}
+	return time.Duration(rpcErr.Argument) * time.Second, true
+}
+
+func isTransient(err error) bool {
+	if rpcErr, ok := tgerr.As(err); ok {
+		return rpcErr.Code == 500
+	}
+
+	var netErr interface{ Timeout() bool }
+	if errors.As(err, &netErr) {
+		return netErr.Timeout()
+	}
+	return false
+}
+
+func (f *Forwarder) sleep(ctx context.Context, d time.Duration) error {
+	select {
+	case <-time.After(d):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// sleepBackoff sleeps an exponentially growing, jittered duration for the
+// given attempt number (0-indexed), capped at maxTransientBackoff.
+func (f *Forwarder) sleepBackoff(ctx context.Context, attempt int) error {
+	backoff := time.Duration(math.Pow(2, float64(attempt))) * 500 * time.Millisecond
+	if backoff > maxTransientBackoff {
+		backoff = maxTransientBackoff
+	}
+	jitter := time.Duration(f.randInt63n(int64(backoff) + 1))
+
+	return f.sleep(ctx, backoff/2+jitter/2)
+}
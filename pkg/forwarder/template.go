@@ -0,0 +1,58 @@
+package forwarder
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/tg"
+)
+
+// TemplateView is what a caption template sees via {{.}} when it runs over
+// a message being forwarded.
+type TemplateView struct {
+	From      int64
+	To        int64
+	Text      string
+	MessageID int
+}
+
+// TemplateTransform compiles tmplText, a Go text/template over a
+// TemplateView, into an Options.Transform. It's the piece a CLI flag (e.g.
+// --caption) would hand to Options without needing a recompile: the flag
+// value is just the template source.
+//
+// The rendered text always comes back with nil entities unless it's
+// identical to the source message: substituting into the template shifts or
+// removes bytes, and the source entities' offsets are UTF-16 code unit
+// positions into the *original* text, so they no longer point at the right
+// spans once the text changes. There's no general way to remap them, so
+// "safely" here means dropping stale offsets rather than attaching them to
+// text they no longer describe.
+func TemplateTransform(tmplText string) (func(ctx context.Context, elem *Elem, msg *tg.Message) (string, []tg.MessageEntityClass, tg.ReplyMarkupClass, bool, error), error) {
+	tmpl, err := template.New("caption").Parse(tmplText)
+	if err != nil {
+		return nil, errors.Wrap(err, "parse caption template")
+	}
+
+	return func(_ context.Context, elem *Elem, msg *tg.Message) (string, []tg.MessageEntityClass, tg.ReplyMarkupClass, bool, error) {
+		view := TemplateView{
+			From:      elem.From.ID(),
+			To:        elem.To.ID(),
+			Text:      msg.Message,
+			MessageID: msg.ID,
+		}
+
+		var buf bytes.Buffer
+		if err := tmpl.Execute(&buf, view); err != nil {
+			return "", nil, nil, false, errors.Wrap(err, "execute caption template")
+		}
+		text := buf.String()
+
+		if text == msg.Message {
+			return text, msg.Entities, msg.ReplyMarkup, true, nil
+		}
+		return text, nil, msg.ReplyMarkup, true, nil
+	}, nil
+}
@@ -0,0 +1,260 @@
+package forwarder
+
+import (
+	"context"
+
+	"github.com/go-faster/errors"
+	"github.com/gotd/td/telegram/peers"
+	"github.com/gotd/td/tg"
+)
+
+// topicCacheKey identifies one resolved (destination channel, topic title)
+// pairing, so a run forwarding many messages from the same source topic only
+// looks it up (or creates it) once.
+type topicCacheKey struct {
+	channel int64
+	title   string
+}
+
+// titleCacheKey identifies one source forum topic, so its title is only
+// ever looked up via channels.GetForumTopicsByID once per (channel, topic),
+// not once per message posted in it.
+type titleCacheKey struct {
+	channel int64
+	topic   int
+}
+
+// resolveTopic returns the InputReplyToClass to send elem's message with: the
+// explicit elem.ToTopic if set, or, failing that, an attempt to auto-map the
+// source message's own forum topic onto a same-titled topic in the
+// destination forum. It returns a nil InputReplyToClass (not an error) for
+// the common case of a non-forum destination or a source message that isn't
+// in a topic.
+func (f *Forwarder) resolveTopic(ctx context.Context, elem *Elem) (tg.InputReplyToClass, error) {
+	if elem.ToTopic != 0 {
+		return topicReplyTo(elem.ToTopic), nil
+	}
+
+	dst, ok := elem.To.(peers.Channel)
+	if !ok || !dst.Raw().GetForum() {
+		return nil, nil
+	}
+
+	srcTopic, ok := topicOf(elem.Msg)
+	if !ok {
+		return nil, nil
+	}
+
+	topicID, err := f.mappedTopic(ctx, elem.From, dst, srcTopic)
+	if err != nil {
+		return nil, err
+	}
+	if topicID == 0 {
+		return nil, nil
+	}
+	return topicReplyTo(topicID), nil
+}
+
+// topicOf reports the root message id of the forum topic msg was posted in,
+// if any.
+func topicOf(msg *tg.Message) (int, bool) {
+	reply, ok := msg.GetReplyTo()
+	if !ok {
+		return 0, false
+	}
+
+	header, ok := reply.(*tg.MessageReplyHeader)
+	if !ok || !header.ForumTopic {
+		return 0, false
+	}
+
+	if top, ok := header.GetReplyToTopID(); ok {
+		return top, true
+	}
+	// a message posted directly into a topic (not replying to another
+	// message in it) carries no ReplyToTopID; its ReplyToMsgID is then the
+	// topic root's id, so that is the topic id.
+	if id, ok := header.GetReplyToMsgID(); ok {
+		return id, true
+	}
+	return 0, false
+}
+
+func topicReplyTo(topMsgID int) tg.InputReplyToClass {
+	// reply_to_msg_id is mandatory on inputReplyToMessage; to post a new
+	// message into a forum topic (rather than reply to one within it),
+	// clients set it to the topic root's own id, same as TopMsgID.
+	r := &tg.InputReplyToMessage{ReplyToMsgID: topMsgID, TopMsgID: topMsgID}
+	r.SetFlags()
+	return r
+}
+
+// mappedTopic resolves srcTopic (a topic root message id in from) to the id
+// of the same-titled topic in dst, creating it via channels.CreateForumTopic
+// when missing and f.opts.CreateTopics is set. Both the source topic's title
+// and the resolved destination id are cached (by (from, srcTopic) and
+// (dst, title) respectively), so a whole topic's worth of messages costs at
+// most one title lookup and one destination lookup/create, not one per
+// message. A destination with no same-titled topic (and CreateTopics off) is
+// not cached, so it is looked up again for each message.
+func (f *Forwarder) mappedTopic(ctx context.Context, from peers.Peer, dst peers.Channel, srcTopic int) (int, error) {
+	srcCh, ok := from.(peers.Channel)
+	if !ok {
+		return 0, nil
+	}
+
+	titleKey := titleCacheKey{channel: srcCh.ID(), topic: srcTopic}
+	title, ok := f.titleCacheGet(titleKey)
+	if !ok {
+		var err error
+		title, err = f.topicTitle(ctx, srcCh, srcTopic)
+		if err != nil {
+			return 0, errors.Wrap(err, "get source topic title")
+		}
+		f.titleCacheSet(titleKey, title)
+	}
+	if title == "" {
+		return 0, nil
+	}
+
+	key := topicCacheKey{channel: dst.ID(), title: title}
+	if id, ok := f.topicCacheGet(key); ok {
+		return id, nil
+	}
+
+	client := f.opts.Pool.Default(ctx)
+
+	id, err := findForumTopic(ctx, client, dst, title)
+	if err != nil {
+		return 0, errors.Wrap(err, "list destination topics")
+	}
+	if id == 0 && f.opts.CreateTopics {
+		id, err = createForumTopic(ctx, client, dst, title, f.randInt63())
+		if err != nil {
+			return 0, errors.Wrap(err, "create destination topic")
+		}
+	}
+	if id == 0 {
+		return 0, nil
+	}
+
+	f.topicCacheSet(key, id)
+	return id, nil
+}
+
+func (f *Forwarder) topicCacheGet(key topicCacheKey) (int, bool) {
+	f.topicMu.Lock()
+	defer f.topicMu.Unlock()
+
+	if f.topicCache == nil {
+		return 0, false
+	}
+	id, ok := f.topicCache[key]
+	return id, ok
+}
+
+func (f *Forwarder) topicCacheSet(key topicCacheKey, id int) {
+	f.topicMu.Lock()
+	defer f.topicMu.Unlock()
+
+	if f.topicCache == nil {
+		f.topicCache = make(map[topicCacheKey]int)
+	}
+	f.topicCache[key] = id
+}
+
+func (f *Forwarder) titleCacheGet(key titleCacheKey) (string, bool) {
+	f.topicMu.Lock()
+	defer f.topicMu.Unlock()
+
+	if f.titleCache == nil {
+		return "", false
+	}
+	title, ok := f.titleCache[key]
+	return title, ok
+}
+
+func (f *Forwarder) titleCacheSet(key titleCacheKey, title string) {
+	f.topicMu.Lock()
+	defer f.topicMu.Unlock()
+
+	if f.titleCache == nil {
+		f.titleCache = make(map[titleCacheKey]string)
+	}
+	f.titleCache[key] = title
+}
+
+// topicTitle looks up srcTopic's title in ch via channels.GetForumTopicsByID.
+func (f *Forwarder) topicTitle(ctx context.Context, ch peers.Channel, srcTopic int) (string, error) {
+	client := f.opts.Pool.Default(ctx)
+
+	res, err := client.ChannelsGetForumTopicsByID(ctx, &tg.ChannelsGetForumTopicsByIDRequest{
+		Channel: ch.InputChannel(),
+		Topics:  []int{srcTopic},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range res.Topics {
+		if topic, ok := t.(*tg.ForumTopic); ok && topic.ID == srcTopic {
+			return topic.Title, nil
+		}
+	}
+	return "", nil
+}
+
+// findForumTopic returns the id of dst's topic titled title, or 0 if none
+// matches.
+func findForumTopic(ctx context.Context, client *tg.Client, dst peers.Channel, title string) (int, error) {
+	res, err := client.ChannelsGetForumTopics(ctx, &tg.ChannelsGetForumTopicsRequest{
+		Channel: dst.InputChannel(),
+		Limit:   100,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, t := range res.Topics {
+		if topic, ok := t.(*tg.ForumTopic); ok && topic.Title == title {
+			return topic.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// createForumTopic creates a topic titled title in dst and returns its root
+// message id, extracted from the service message the RPC's updates carry.
+func createForumTopic(ctx context.Context, client *tg.Client, dst peers.Channel, title string, randomID int64) (int, error) {
+	req := &tg.ChannelsCreateForumTopicRequest{
+		Channel:  dst.InputChannel(),
+		Title:    title,
+		RandomID: randomID,
+	}
+	req.SetFlags()
+
+	updates, err := client.ChannelsCreateForumTopic(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, u := range updatesClasses(updates) {
+		if upd, ok := u.(*tg.UpdateNewChannelMessage); ok {
+			return upd.Message.GetID(), nil
+		}
+	}
+	return 0, errors.New("no topic-creation message in response updates")
+}
+
+// updatesClasses normalizes the handful of tg.UpdatesClass shapes an RPC can
+// return down to a flat update list.
+func updatesClasses(u tg.UpdatesClass) []tg.UpdateClass {
+	switch v := u.(type) {
+	case *tg.Updates:
+		return v.Updates
+	case *tg.UpdatesCombined:
+		return v.Updates
+	default:
+		return nil
+	}
+}